@@ -0,0 +1,57 @@
+package main
+
+import "math"
+
+// aqiBreakpoint is one row of a US EPA breakpoint table used to linearly
+// interpolate a pollutant concentration into an Air Quality Index value.
+type aqiBreakpoint struct {
+	concLow, concHigh float64
+	aqiLow, aqiHigh   float64
+}
+
+// pm25Breakpoints are the EPA breakpoints for PM2.5, in µg/m3 (24-hour avg).
+var pm25Breakpoints = []aqiBreakpoint{
+	{0.0, 9.0, 0, 50},
+	{9.1, 35.4, 51, 100},
+	{35.5, 55.4, 101, 150},
+	{55.5, 125.4, 151, 200},
+	{125.5, 225.4, 201, 300},
+	{225.5, 325.4, 301, 400},
+	{325.5, 500.4, 401, 500},
+}
+
+// pm10Breakpoints are the EPA breakpoints for PM10, in µg/m3 (24-hour avg).
+var pm10Breakpoints = []aqiBreakpoint{
+	{0, 54, 0, 50},
+	{55, 154, 51, 100},
+	{155, 254, 101, 150},
+	{255, 354, 151, 200},
+	{355, 424, 201, 300},
+	{425, 504, 301, 400},
+	{505, 604, 401, 500},
+}
+
+// aqi converts a pollutant concentration into a US EPA AQI value by linear
+// interpolation between breakpoints. The EPA algorithm truncates the raw
+// concentration to the table's precision before lookup; without this, values
+// that fall between one breakpoint's high and the next's low (e.g. 9.05 for
+// PM2.5, which is below 9.1 but above 9.0) would match no row. ok is false if
+// concentration falls outside the table (negative, or above the top
+// breakpoint).
+func aqi(breakpoints []aqiBreakpoint, precision, concentration float64) (value float64, ok bool) {
+	if concentration < 0 {
+		return 0, false
+	}
+	concentration = math.Floor(concentration/precision) * precision
+
+	for _, bp := range breakpoints {
+		if concentration >= bp.concLow && concentration <= bp.concHigh {
+			return (bp.aqiHigh-bp.aqiLow)/(bp.concHigh-bp.concLow)*(concentration-bp.concLow) + bp.aqiLow, true
+		}
+	}
+
+	return 0, false
+}
+
+func pm25AQI(concentration float64) (float64, bool) { return aqi(pm25Breakpoints, 0.1, concentration) }
+func pm10AQI(concentration float64) (float64, bool) { return aqi(pm10Breakpoints, 1, concentration) }
@@ -0,0 +1,211 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	bme280I2CAddress = 0x76
+
+	bme280RegCalib00  = 0x88
+	bme280RegCalibH1  = 0xA1
+	bme280RegCalibH2  = 0xE1
+	bme280RegCtrlHum  = 0xF2
+	bme280RegCtrlMeas = 0xF4
+	bme280RegConfig   = 0xF5
+	bme280RegData     = 0xF7
+
+	// Normal mode, 1x oversampling on temperature and pressure.
+	bme280CtrlMeasNormal = 0x27
+	bme280CtrlHum1x      = 0x01
+)
+
+// bme280Calib holds the factory calibration coefficients read out of the
+// sensor's NVM at startup, used to compensate raw ADC readings.
+type bme280Calib struct {
+	t1 uint16
+	t2 int16
+	t3 int16
+
+	p1 uint16
+	p2 int16
+	p3 int16
+	p4 int16
+	p5 int16
+	p6 int16
+	p7 int16
+	p8 int16
+	p9 int16
+
+	h1 uint8
+	h2 int16
+	h3 uint8
+	h4 int16
+	h5 int16
+	h6 int8
+}
+
+type bme280Sensor struct {
+	f     *os.File
+	calib bme280Calib
+}
+
+func newBme280Sensor(port string) (EnvSensor, error) {
+	f, err := os.OpenFile(port, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.IoctlSetInt(int(f.Fd()), i2cSlave, bme280I2CAddress); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	s := &bme280Sensor{f: f}
+
+	if err := s.readCalibration(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if _, err := f.Write([]byte{bme280RegCtrlHum, bme280CtrlHum1x}); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Write([]byte{bme280RegCtrlMeas, bme280CtrlMeasNormal}); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *bme280Sensor) readRegs(reg byte, n int) ([]byte, error) {
+	if _, err := s.f.Write([]byte{reg}); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(s.f, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (s *bme280Sensor) readCalibration() error {
+	c1, err := s.readRegs(bme280RegCalib00, 26)
+	if err != nil {
+		return err
+	}
+	c2, err := s.readRegs(bme280RegCalibH2, 7)
+	if err != nil {
+		return err
+	}
+
+	s.calib = parseBme280Calib(c1, c2)
+	return nil
+}
+
+// parseBme280Calib decodes the calib00 (registers 0x88-0xA1) and calibH2
+// (registers 0xE1-0xE7) calibration blocks into their individual
+// temperature/pressure/humidity coefficients.
+func parseBme280Calib(c1, c2 []byte) bme280Calib {
+	le16 := binary.LittleEndian.Uint16
+	calib := bme280Calib{
+		t1: le16(c1[0:2]),
+		t2: int16(le16(c1[2:4])),
+		t3: int16(le16(c1[4:6])),
+		p1: le16(c1[6:8]),
+		p2: int16(le16(c1[8:10])),
+		p3: int16(le16(c1[10:12])),
+		p4: int16(le16(c1[12:14])),
+		p5: int16(le16(c1[14:16])),
+		p6: int16(le16(c1[16:18])),
+		p7: int16(le16(c1[18:20])),
+		p8: int16(le16(c1[20:22])),
+		p9: int16(le16(c1[22:24])),
+		h1: c1[25],
+	}
+
+	calib.h2 = int16(le16(c2[0:2]))
+	calib.h3 = c2[2]
+	calib.h4 = int16(c2[3])<<4 | int16(c2[4]&0x0f)
+	calib.h5 = int16(c2[5])<<4 | int16(c2[4]>>4)
+	calib.h6 = int8(c2[6])
+
+	return calib
+}
+
+func (s *bme280Sensor) Read() (EnvMeasurement, error) {
+	data, err := s.readRegs(bme280RegData, 8)
+	if err != nil {
+		return EnvMeasurement{}, err
+	}
+
+	rawPress := int32(data[0])<<12 | int32(data[1])<<4 | int32(data[2])>>4
+	rawTemp := int32(data[3])<<12 | int32(data[4])<<4 | int32(data[5])>>4
+	rawHum := int32(data[6])<<8 | int32(data[7])
+
+	temp, tFine := s.calib.compensateTemp(rawTemp)
+	pressure := s.calib.compensatePressure(rawPress, tFine)
+	humidity := s.calib.compensateHumidity(rawHum, tFine)
+
+	return EnvMeasurement{
+		TemperatureCelsius: temp,
+		HumidityPercent:    humidity,
+		PressureHPa:        pressure,
+	}, nil
+}
+
+// compensateTemp applies the Bosch BME280 temperature compensation formula,
+// returning both the temperature in °C and t_fine, which the pressure and
+// humidity compensation formulas also depend on.
+func (c bme280Calib) compensateTemp(raw int32) (celsius, tFine float64) {
+	v1 := (float64(raw)/16384.0 - float64(c.t1)/1024.0) * float64(c.t2)
+	v2 := (float64(raw)/131072.0 - float64(c.t1)/8192.0) * (float64(raw)/131072.0 - float64(c.t1)/8192.0) * float64(c.t3)
+	tFine = v1 + v2
+	return tFine / 5120.0, tFine
+}
+
+func (c bme280Calib) compensatePressure(raw int32, tFine float64) float64 {
+	v1 := tFine/2.0 - 64000.0
+	v2 := v1 * v1 * float64(c.p6) / 32768.0
+	v2 += v1 * float64(c.p5) * 2.0
+	v2 = v2/4.0 + float64(c.p4)*65536.0
+	v1 = (float64(c.p3)*v1*v1/524288.0 + float64(c.p2)*v1) / 524288.0
+	v1 = (1.0 + v1/32768.0) * float64(c.p1)
+	if v1 == 0 {
+		return 0
+	}
+
+	p := 1048576.0 - float64(raw)
+	p = (p - v2/4096.0) * 6250.0 / v1
+	v1 = float64(c.p9) * p * p / 2147483648.0
+	v2 = p * float64(c.p8) / 32768.0
+	p += (v1 + v2 + float64(c.p7)) / 16.0
+
+	return p / 100.0
+}
+
+func (c bme280Calib) compensateHumidity(raw int32, tFine float64) float64 {
+	h := tFine - 76800.0
+	h = (float64(raw) - (float64(c.h4)*64.0 + float64(c.h5)/16384.0*h)) *
+		(float64(c.h2) / 65536.0 * (1.0 + float64(c.h6)/67108864.0*h*(1.0+float64(c.h3)/67108864.0*h)))
+	h *= 1.0 - float64(c.h1)*h/524288.0
+
+	if h > 100.0 {
+		h = 100.0
+	}
+	if h < 0.0 {
+		h = 0.0
+	}
+	return h
+}
+
+func (s *bme280Sensor) Close() error {
+	return s.f.Close()
+}
@@ -0,0 +1,56 @@
+//go:build linux
+
+package main
+
+import "testing"
+
+func TestParseBme280Calib(t *testing.T) {
+	c1 := make([]byte, 26)
+	c1[0], c1[1] = 0x01, 0x00   // t1
+	c1[2], c1[3] = 0x02, 0x00   // t2
+	c1[4], c1[5] = 0x03, 0x00   // t3
+	c1[6], c1[7] = 0x04, 0x00   // p1
+	c1[8], c1[9] = 0x05, 0x00   // p2
+	c1[10], c1[11] = 0x06, 0x00 // p3
+	c1[12], c1[13] = 0x07, 0x00 // p4
+	c1[14], c1[15] = 0x08, 0x00 // p5
+	c1[16], c1[17] = 0x09, 0x00 // p6
+	c1[18], c1[19] = 0x0A, 0x00 // p7
+	c1[20], c1[21] = 0x0B, 0x00 // p8
+	c1[22], c1[23] = 0x0C, 0x00 // p9
+	c1[25] = 0x0D               // h1
+
+	c2 := make([]byte, 7)
+	c2[0], c2[1] = 0x0E, 0x00 // h2
+	c2[2] = 0x0F              // h3
+	c2[3] = 0x01              // h4 high nibble
+	c2[4] = 0x02              // h4 low nibble / h5 high nibble
+	c2[5] = 0x03              // h5 low nibble
+	c2[6] = 0x04              // h6
+
+	want := bme280Calib{
+		t1: 1,
+		t2: 2,
+		t3: 3,
+		p1: 4,
+		p2: 5,
+		p3: 6,
+		p4: 7,
+		p5: 8,
+		p6: 9,
+		p7: 10,
+		p8: 11,
+		p9: 12,
+		h1: 13,
+		h2: 14,
+		h3: 15,
+		h4: 18,
+		h5: 48,
+		h6: 4,
+	}
+
+	got := parseBme280Calib(c1, c2)
+	if got != want {
+		t.Errorf("parseBme280Calib() = %+v, want %+v", got, want)
+	}
+}
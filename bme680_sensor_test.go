@@ -0,0 +1,62 @@
+//go:build linux
+
+package main
+
+import "testing"
+
+func TestParseBme680Calib(t *testing.T) {
+	c1 := make([]byte, 25)
+	c1[1], c1[2] = 0x34, 0x12 // t2
+	c1[3] = 0x05              // t3
+
+	c1[5], c1[6] = 0x11, 0x22   // p1
+	c1[7], c1[8] = 0x02, 0x00   // p2
+	c1[9] = 0x03                // p3
+	c1[11], c1[12] = 0x04, 0x00 // p4
+	c1[13], c1[14] = 0x05, 0x00 // p5
+	c1[15] = 0x07               // p7
+	c1[16] = 0x06               // p6
+	c1[19], c1[20] = 0x08, 0x00 // p8
+	c1[21], c1[22] = 0x09, 0x00 // p9
+	c1[23] = 0x0A               // p10
+
+	c2 := make([]byte, 16)
+	c2[0] = 0x05              // h2 high nibble
+	c2[2] = 0x09              // h1 high nibble
+	c2[3] = 0x0B              // h3
+	c2[4] = 0x0C              // h4
+	c2[5] = 0x0D              // h5
+	c2[6] = 0x0E              // h6
+	c2[7] = 0x0F              // h7
+	c2[8], c2[9] = 0x10, 0x00 // t1
+
+	want := bme680Calib{
+		t1: 16,
+		t2: 0x1234,
+		t3: 5,
+
+		p1:  0x2211,
+		p2:  2,
+		p3:  3,
+		p4:  4,
+		p5:  5,
+		p6:  6,
+		p7:  7,
+		p8:  8,
+		p9:  9,
+		p10: 10,
+
+		h1: 144,
+		h2: 80,
+		h3: 11,
+		h4: 12,
+		h5: 13,
+		h6: 14,
+		h7: 15,
+	}
+
+	got := parseBme680Calib(c1, c2)
+	if got != want {
+		t.Errorf("parseBme680Calib() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const defaultSensorName = "default"
+
+// sensorConfig describes one "[sensor.NAME]" section from the config file.
+type sensorConfig struct {
+	Name   string
+	Driver string
+	Port   string
+	Cycle  int
+}
+
+// loadSensorConfigs reads path for one or more sensor definitions. If path
+// doesn't exist, it falls back to a single SDS011 sensor built from the
+// port-path/cycle flags, preserving behaviour for existing single-sensor
+// deployments.
+func loadSensorConfigs(path string) ([]sensorConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []sensorConfig{{
+				Name:   defaultSensorName,
+				Driver: "sds011",
+				Port:   *portPath,
+				Cycle:  *cycle,
+			}}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var configs []sensorConfig
+	var current *sensorConfig
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			section := strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
+			name, ok := strings.CutPrefix(section, "sensor.")
+			if !ok {
+				return nil, fmt.Errorf("config: unknown section %q", section)
+			}
+			configs = append(configs, sensorConfig{Name: name, Cycle: *cycle})
+			current = &configs[len(configs)-1]
+			continue
+		}
+
+		if current == nil {
+			return nil, fmt.Errorf("config: key outside of a [sensor.*] section: %q", line)
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("config: invalid line: %q", line)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "driver":
+			current.Driver = value
+		case "port":
+			current.Port = value
+		case "cycle":
+			c, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("config: invalid cycle %q: %w", value, err)
+			}
+			current.Cycle = c
+		default:
+			return nil, fmt.Errorf("config: unknown key %q", key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("config: no [sensor.*] sections found in %s", path)
+	}
+
+	for _, cfg := range configs {
+		if _, ok := sensorDrivers[cfg.Driver]; !ok {
+			return nil, fmt.Errorf("config: sensor %q: unknown driver %q", cfg.Name, cfg.Driver)
+		}
+		if cfg.Port == "" {
+			return nil, fmt.Errorf("config: sensor %q: port is required", cfg.Name)
+		}
+	}
+
+	return configs, nil
+}
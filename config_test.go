@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.ini")
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("writing test config: %v", err)
+	}
+	return path
+}
+
+func TestLoadSensorConfigsMissingFileFallsBackToFlags(t *testing.T) {
+	configs, err := loadSensorConfigs(filepath.Join(t.TempDir(), "does-not-exist.ini"))
+	if err != nil {
+		t.Fatalf("loadSensorConfigs() error = %v", err)
+	}
+	if len(configs) != 1 {
+		t.Fatalf("len(configs) = %d, want 1", len(configs))
+	}
+	got := configs[0]
+	if got.Name != defaultSensorName || got.Driver != "sds011" || got.Port != *portPath || got.Cycle != *cycle {
+		t.Errorf("configs[0] = %+v, want {%s sds011 %s %d}", got, defaultSensorName, *portPath, *cycle)
+	}
+}
+
+func TestLoadSensorConfigsMultipleSections(t *testing.T) {
+	path := writeConfig(t, `
+[sensor.living-room]
+driver = sds011
+port = /dev/ttyUSB0
+cycle = 10
+
+[sensor.garage]
+driver = sps30
+port = /dev/i2c-1
+`)
+
+	configs, err := loadSensorConfigs(path)
+	if err != nil {
+		t.Fatalf("loadSensorConfigs() error = %v", err)
+	}
+	if len(configs) != 2 {
+		t.Fatalf("len(configs) = %d, want 2", len(configs))
+	}
+
+	want := []sensorConfig{
+		{Name: "living-room", Driver: "sds011", Port: "/dev/ttyUSB0", Cycle: 10},
+		{Name: "garage", Driver: "sps30", Port: "/dev/i2c-1", Cycle: *cycle},
+	}
+	for i, w := range want {
+		if configs[i] != w {
+			t.Errorf("configs[%d] = %+v, want %+v", i, configs[i], w)
+		}
+	}
+}
+
+func TestLoadSensorConfigsUnknownDriver(t *testing.T) {
+	path := writeConfig(t, `
+[sensor.living-room]
+driver = not-a-real-driver
+port = /dev/ttyUSB0
+`)
+
+	if _, err := loadSensorConfigs(path); err == nil {
+		t.Fatal("loadSensorConfigs() error = nil, want error for unknown driver")
+	}
+}
+
+func TestLoadSensorConfigsMissingPort(t *testing.T) {
+	path := writeConfig(t, `
+[sensor.living-room]
+driver = sds011
+`)
+
+	if _, err := loadSensorConfigs(path); err == nil {
+		t.Fatal("loadSensorConfigs() error = nil, want error for missing port")
+	}
+}
+
+func TestLoadSensorConfigsKeyOutsideSection(t *testing.T) {
+	path := writeConfig(t, `driver = sds011`)
+
+	if _, err := loadSensorConfigs(path); err == nil {
+		t.Fatal("loadSensorConfigs() error = nil, want error for key outside section")
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+// EnvMeasurement is a single reading from an optional temperature/humidity/
+// pressure/gas companion sensor.
+type EnvMeasurement struct {
+	TemperatureCelsius float64
+	HumidityPercent    float64
+	PressureHPa        float64
+	GasResistanceOhms  float64
+	HaveGas            bool
+}
+
+// EnvSensor is implemented by companion environmental sensor drivers.
+type EnvSensor interface {
+	Read() (EnvMeasurement, error)
+	Close() error
+}
+
+// EnvSensorFactory connects to an environmental sensor reachable at port.
+type EnvSensorFactory func(port string) (EnvSensor, error)
+
+// envSensorDrivers maps an --env-sensor value to its factory. Drivers that
+// depend on platform-specific access (e.g. I2C) register themselves from an
+// init in their own build-tagged file instead of being listed here directly,
+// so the package still compiles on platforms that lack that access.
+var envSensorDrivers = map[string]EnvSensorFactory{}
@@ -0,0 +1,8 @@
+//go:build linux
+
+package main
+
+func init() {
+	envSensorDrivers["bme280"] = newBme280Sensor
+	envSensorDrivers["bme680"] = newBme680Sensor
+}
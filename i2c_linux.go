@@ -0,0 +1,9 @@
+//go:build linux
+
+package main
+
+// i2cSlave is Linux's I2C_SLAVE ioctl request number, from
+// <linux/i2c-dev.h>. It isn't exposed by golang.org/x/sys/unix, so every
+// I2C driver in this package shares this one local definition instead of
+// each redefining it.
+const i2cSlave = 0x0703
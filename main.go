@@ -3,22 +3,26 @@ package main
 import (
 	"fmt"
 	"log/slog"
+	"math/rand"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/ryszard/sds011/go/sds011"
 	flag "github.com/spf13/pflag"
 )
 
 const (
-	ver string = "0.27"
-	// 0 retries, exit on failure
-	retries        int = 0
-	apiCallTimeout int = 10
+	ver string = "0.32"
+
+	apiCallTimeout      = 10 * time.Second
+	opRetries           = 3
+	backoffBase         = time.Second
+	backoffCap          = 60 * time.Second
+	maxReconnectAttempt = 10
 )
 
 var (
@@ -28,109 +32,386 @@ var (
 	cycle         = flag.Int("cycle", 5, "Sensor cycle length in minutes")
 	forceSetCycle = flag.Bool("force-set-cycle", true, "Force set cycle on every program start")
 	verbose       = flag.Bool("verbose", false, "Enable verbose output")
+
+	envSensorDriver = flag.String("env-sensor", "", "Optional companion temperature/humidity/pressure sensor driver (bme280, bme680)")
+	envPortPath     = flag.String("env-port-path", "/dev/i2c-1", "Companion sensor I2C bus device path")
+
+	dutyCycle        = flag.Bool("duty-cycle", false, "Sleep the SDS011 between samples to extend laser lifetime, instead of running it continuously")
+	dutyCycleWarmup  = flag.Duration("duty-cycle-warmup", 30*time.Second, "Time to wait after waking the sensor before sampling, to let the fan stabilize the reading")
+	dutyCycleSamples = flag.Int("duty-cycle-samples", 3, "Number of samples to average per duty-cycle wake")
 )
 
 var (
-	airqualityPM = promauto.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "airquality_pm",
-		Help: "Airquality PM metric",
+	sensorReconnectsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "airquality_sensor_reconnects_total",
+		Help: "Total number of sensor reconnect attempts",
 	},
-		[]string{"type"})
+		[]string{"sensor"})
+	sensorErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "airquality_sensor_errors_total",
+		Help: "Total number of sensor operation errors",
+	},
+		[]string{"sensor", "op"})
 )
 
-func sensorMakePassive(sensor *sds011.Sensor) error {
-	var responseError error
+// sensorState holds the last refresh outcome and cached reading for one
+// configured sensor.
+type sensorState struct {
+	up                  bool
+	lastRefreshTime     time.Time
+	lastRefreshDuration time.Duration
+	cacheUpdatedTime    time.Time
+	cache               Measurement
+	haveCache           bool
+}
+
+// collector implements prometheus.Collector over an arbitrary number of
+// Sensors, each identified by a "sensor" label. Polling runs on its own
+// cadence per sensor in the background and Collect serves the last cached
+// reading, decoupling scrape cadence from sensor cycle length and letting
+// scrape failures surface as airquality_up=0 instead of killing the
+// process.
+type collector struct {
+	upDesc                  *prometheus.Desc
+	lastRefreshTimeDesc     *prometheus.Desc
+	lastRefreshDurationDesc *prometheus.Desc
+	cacheUpdatedTimeDesc    *prometheus.Desc
+	pmDesc                  *prometheus.Desc
+	aqiDesc                 *prometheus.Desc
+
+	temperatureDesc   *prometheus.Desc
+	humidityDesc      *prometheus.Desc
+	pressureDesc      *prometheus.Desc
+	gasResistanceDesc *prometheus.Desc
+
+	mu      sync.Mutex
+	states  map[string]*sensorState
+	haveEnv bool
+	env     EnvMeasurement
+}
+
+func newCollector() *collector {
+	return &collector{
+		upDesc: prometheus.NewDesc(
+			"airquality_up",
+			"Whether the last sensor refresh succeeded",
+			[]string{"sensor"}, nil),
+		lastRefreshTimeDesc: prometheus.NewDesc(
+			"airquality_last_refresh_time",
+			"Unix timestamp of the last sensor refresh attempt",
+			[]string{"sensor"}, nil),
+		lastRefreshDurationDesc: prometheus.NewDesc(
+			"airquality_last_refresh_duration_seconds",
+			"Duration of the last sensor refresh attempt",
+			[]string{"sensor"}, nil),
+		cacheUpdatedTimeDesc: prometheus.NewDesc(
+			"airquality_cache_updated_time",
+			"Unix timestamp of the last successful sensor reading",
+			[]string{"sensor"}, nil),
+		pmDesc: prometheus.NewDesc(
+			"airquality_pm",
+			"Airquality PM metric",
+			[]string{"sensor", "type"}, nil),
+		aqiDesc: prometheus.NewDesc(
+			"airquality_aqi",
+			"US EPA Air Quality Index, computed from the cached PM reading",
+			[]string{"sensor", "pollutant"}, nil),
+		temperatureDesc: prometheus.NewDesc(
+			"airquality_temperature_celsius",
+			"Companion sensor temperature reading",
+			nil, nil),
+		humidityDesc: prometheus.NewDesc(
+			"airquality_humidity_percent",
+			"Companion sensor relative humidity reading",
+			nil, nil),
+		pressureDesc: prometheus.NewDesc(
+			"airquality_pressure_hpa",
+			"Companion sensor barometric pressure reading",
+			nil, nil),
+		gasResistanceDesc: prometheus.NewDesc(
+			"airquality_gas_resistance_ohms",
+			"Companion sensor VOC gas resistance reading (BME680 only)",
+			nil, nil),
+		states: make(map[string]*sensorState),
+	}
+}
 
-	response := make(chan error)
-Loop:
-	for retry := 0; retry <= retries; retry++ {
-		if retry > 0 {
-			slog.Debug("Retrying API call", "retry", retry)
-			time.Sleep(time.Second * time.Duration(retry))
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.upDesc
+	ch <- c.lastRefreshTimeDesc
+	ch <- c.lastRefreshDurationDesc
+	ch <- c.cacheUpdatedTimeDesc
+	ch <- c.pmDesc
+	ch <- c.aqiDesc
+	ch <- c.temperatureDesc
+	ch <- c.humidityDesc
+	ch <- c.pressureDesc
+	ch <- c.gasResistanceDesc
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name, s := range c.states {
+		up := 0.0
+		if s.up {
+			up = 1.0
 		}
+		ch <- prometheus.MustNewConstMetric(c.upDesc, prometheus.GaugeValue, up, name)
+
+		if !s.lastRefreshTime.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.lastRefreshTimeDesc, prometheus.GaugeValue, float64(s.lastRefreshTime.Unix()), name)
+			ch <- prometheus.MustNewConstMetric(c.lastRefreshDurationDesc, prometheus.GaugeValue, s.lastRefreshDuration.Seconds(), name)
+		}
+
+		if s.haveCache {
+			ch <- prometheus.MustNewConstMetric(c.cacheUpdatedTimeDesc, prometheus.GaugeValue, float64(s.cacheUpdatedTime.Unix()), name)
+			ch <- prometheus.MustNewConstMetric(c.pmDesc, prometheus.GaugeValue, s.cache.PM25, name, "pm2.5")
+			ch <- prometheus.MustNewConstMetric(c.pmDesc, prometheus.GaugeValue, s.cache.PM10, name, "pm10")
 
-		go func() {
-			if err := sensor.MakePassive(); err == nil {
-				response <- nil
-			} else {
-				slog.Warn("Cannot switch sensor to passive mode", "error", err)
-				response <- fmt.Errorf("Cannot switch sensor to passive mode: %v", err)
+			if v, ok := pm25AQI(s.cache.PM25); ok {
+				ch <- prometheus.MustNewConstMetric(c.aqiDesc, prometheus.GaugeValue, v, name, "pm2.5")
 			}
-		}()
-
-		select {
-		case err := <-response:
-			if err == nil {
-				responseError = nil
-				break Loop
-			} else {
-				responseError = err
-				continue Loop
+			if v, ok := pm10AQI(s.cache.PM10); ok {
+				ch <- prometheus.MustNewConstMetric(c.aqiDesc, prometheus.GaugeValue, v, name, "pm10")
 			}
-		case <-time.After(time.Second * time.Duration(apiCallTimeout)):
-			slog.Warn("Device API response timeout", "retries", retry)
-			responseError = fmt.Errorf("Device API response timeout (%d retries)", retry)
-			continue Loop
 		}
 	}
 
-	if responseError != nil {
-		return responseError
+	if c.haveEnv {
+		ch <- prometheus.MustNewConstMetric(c.temperatureDesc, prometheus.GaugeValue, c.env.TemperatureCelsius)
+		ch <- prometheus.MustNewConstMetric(c.humidityDesc, prometheus.GaugeValue, c.env.HumidityPercent)
+		ch <- prometheus.MustNewConstMetric(c.pressureDesc, prometheus.GaugeValue, c.env.PressureHPa)
+		if c.env.HaveGas {
+			ch <- prometheus.MustNewConstMetric(c.gasResistanceDesc, prometheus.GaugeValue, c.env.GasResistanceOhms)
+		}
 	}
+}
 
-	return nil
+func (c *collector) stateFor(name string) *sensorState {
+	s, ok := c.states[name]
+	if !ok {
+		s = &sensorState{}
+		c.states[name] = s
+	}
+	return s
 }
 
-func recordMetrics() {
-	sensor, err := sds011.New(*portPath)
-	if err != nil {
-		slog.Error("Cannot create sensor instance", "error", err)
-		os.Exit(1)
+// setRefreshResult records the outcome of a refresh attempt that started at start.
+func (c *collector) setRefreshResult(name string, start time.Time, success bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stateFor(name)
+	s.up = success
+	s.lastRefreshTime = start
+	s.lastRefreshDuration = time.Since(start)
+}
+
+// updateCache stores the latest successful measurement.
+func (c *collector) updateCache(name string, m Measurement) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stateFor(name)
+	s.cache = m
+	s.cacheUpdatedTime = time.Now()
+	s.haveCache = true
+}
+
+// updateEnv stores the latest successful companion sensor reading.
+func (c *collector) updateEnv(m EnvMeasurement) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.env = m
+	c.haveEnv = true
+}
+
+// backoff returns a capped, jittered exponential backoff for the given
+// (zero-based) attempt number.
+func backoff(attempt int) time.Duration {
+	d := backoffBase * time.Duration(1<<uint(attempt))
+	if d > backoffCap {
+		d = backoffCap
 	}
-	defer sensor.Close()
+	return d/2 + time.Duration(rand.Int63n(int64(d/2+1)))
+}
 
-	if err := sensorMakePassive(sensor); err != nil {
-		slog.Error("Cannot switch sensor to passive mode", "error", err)
-		os.Exit(1)
+// withRetry runs fn, retrying on error up to opRetries times with a capped,
+// jittered backoff between attempts, and bounds each attempt by
+// apiCallTimeout since a wedged serial/I2C bus can otherwise hang a call
+// indefinitely. It must only be used for short command/response ops
+// (connect, configure, set_cycle, query, ...). Every failed attempt
+// increments airquality_sensor_errors_total{sensor,op}.
+func withRetry(sensorName, op string, fn func() error) error {
+	return withRetryTimeout(sensorName, op, apiCallTimeout, fn)
+}
+
+// withRetryStreaming is like withRetry but without a timeout, for ops that
+// legitimately block for a long, cycle-dependent time, such as reading the
+// next frame off a sensor's active-mode stream. Bounding those with
+// apiCallTimeout would retry out from under a read that's still in flight on
+// the same serial port and has simply not produced a frame yet.
+func withRetryStreaming(sensorName, op string, fn func() error) error {
+	return withRetryTimeout(sensorName, op, 0, fn)
+}
+
+func withRetryTimeout(sensorName, op string, timeout time.Duration, fn func() error) error {
+	var lastErr error
+
+	for attempt := 0; attempt <= opRetries; attempt++ {
+		if attempt > 0 {
+			d := backoff(attempt - 1)
+			slog.Debug("Retrying sensor operation", "sensor", sensorName, "op", op, "attempt", attempt, "backoff", d)
+			time.Sleep(d)
+		}
+
+		if timeout <= 0 {
+			lastErr = fn()
+		} else {
+			response := make(chan error, 1)
+			go func() {
+				response <- fn()
+			}()
+
+			select {
+			case err := <-response:
+				lastErr = err
+			case <-time.After(timeout):
+				lastErr = fmt.Errorf("timed out after %s", timeout)
+			}
+		}
+
+		if lastErr == nil {
+			return nil
+		}
+
+		slog.Warn("Sensor operation failed", "sensor", sensorName, "op", op, "attempt", attempt, "error", lastErr)
+		sensorErrorsTotal.WithLabelValues(sensorName, op).Inc()
 	}
 
-	if *forceSetCycle {
-		slog.Info("Setting sensor cycle", "minutes", *cycle)
-		if err := sensor.SetCycle(uint8(*cycle)); err != nil {
-			slog.Error("Cannot set current cycle", "error", err)
-			os.Exit(1)
+	return fmt.Errorf("%s: %w", op, lastErr)
+}
+
+// runSensor connects to and configures the sensor described by cfg, then
+// streams measurements into collector until an unrecoverable error occurs,
+// at which point it closes the sensor and returns the error so the caller
+// can reconnect.
+func runSensor(cfg sensorConfig, collector *collector) error {
+	start := time.Now()
+
+	factory := sensorDrivers[cfg.Driver]
+
+	var sensor Sensor
+	if err := withRetry(cfg.Name, "connect", func() error {
+		s, err := factory(cfg.Name, cfg.Port)
+		if err != nil {
+			return err
 		}
-	} else {
-		currentCycle, err := sensor.Cycle()
+		sensor = s
+		return nil
+	}); err != nil {
+		collector.setRefreshResult(cfg.Name, start, false)
+		return err
+	}
+	defer sensor.Close()
+
+	if err := withRetry(cfg.Name, "configure", func() error { return sensor.Configure(cfg.Cycle) }); err != nil {
+		collector.setRefreshResult(cfg.Name, start, false)
+		return err
+	}
+
+	for {
+		pollStart := time.Now()
+
+		m, err := sensor.Read()
 		if err != nil {
-			slog.Error("Cannot get current cycle", "error", err)
-			os.Exit(1)
+			collector.setRefreshResult(cfg.Name, pollStart, false)
+			return err
 		}
-		if currentCycle != uint8(*cycle) {
-			slog.Info("Setting sensor cycle", "minutes", *cycle)
-			if err := sensor.SetCycle(uint8(*cycle)); err != nil {
-				slog.Error("Cannot set current cycle", "error", err)
-				os.Exit(1)
+
+		slog.Info("Sensor measurement results", "sensor", cfg.Name, "data", m)
+		collector.setRefreshResult(cfg.Name, pollStart, true)
+		collector.updateCache(cfg.Name, m)
+	}
+}
+
+// recordMetrics supervises runSensor for one configured sensor, reconnecting
+// with a capped, jittered backoff whenever the sensor session ends in error
+// instead of killing the process.
+func recordMetrics(cfg sensorConfig, collector *collector) {
+	attempt := 0
+	for {
+		if err := runSensor(cfg, collector); err != nil {
+			slog.Error("Sensor session ended, reconnecting", "sensor", cfg.Name, "error", err)
+			if attempt < maxReconnectAttempt {
+				attempt++
 			}
+		} else {
+			attempt = 0
 		}
+
+		sensorReconnectsTotal.WithLabelValues(cfg.Name).Inc()
+		d := backoff(attempt)
+		slog.Info("Reconnecting to sensor", "sensor", cfg.Name, "backoff", d)
+		time.Sleep(d)
 	}
+}
 
-	slog.Info("Switching sensor to active mode")
-	if err := sensor.MakeActive(); err != nil {
-		slog.Error("Cannot switch sensor to active mode", "error", err)
-		os.Exit(1)
+// runEnvSensor connects to the configured companion sensor and streams
+// readings into collector until an unrecoverable error occurs.
+func runEnvSensor(driver, port string, collector *collector) error {
+	factory := envSensorDrivers[driver]
+
+	var sensor EnvSensor
+	if err := withRetry("env", "connect", func() error {
+		s, err := factory(port)
+		if err != nil {
+			return err
+		}
+		sensor = s
+		return nil
+	}); err != nil {
+		return err
 	}
+	defer sensor.Close()
 
 	for {
-		point, err := sensor.Get()
+		var m EnvMeasurement
+		err := withRetry("env", "get", func() error {
+			reading, err := sensor.Read()
+			if err != nil {
+				return err
+			}
+			m = reading
+			return nil
+		})
 		if err != nil {
-			slog.Error("Getting sensor measurement error", "error", err)
-			continue
+			return err
+		}
+
+		slog.Info("Companion sensor measurement results", "data", m)
+		collector.updateEnv(m)
+	}
+}
+
+// recordEnvMetrics supervises runEnvSensor, reconnecting with a capped,
+// jittered backoff whenever the companion sensor session ends in error.
+func recordEnvMetrics(driver, port string, collector *collector) {
+	attempt := 0
+	for {
+		if err := runEnvSensor(driver, port, collector); err != nil {
+			slog.Error("Companion sensor session ended, reconnecting", "error", err)
+			if attempt < maxReconnectAttempt {
+				attempt++
+			}
+		} else {
+			attempt = 0
 		}
 
-		slog.Info("Sensor measurement results", "data", point)
-		airqualityPM.WithLabelValues("pm2.5").Set(point.PM25)
-		airqualityPM.WithLabelValues("pm10").Set(point.PM10)
+		sensorReconnectsTotal.WithLabelValues("env").Inc()
+		d := backoff(attempt)
+		slog.Info("Reconnecting to companion sensor", "backoff", d)
+		time.Sleep(d)
 	}
 }
 
@@ -148,7 +429,28 @@ func main() {
 
 	slog.Info("Starting", "version", ver)
 
-	go recordMetrics()
+	configs, err := loadSensorConfigs(*configFile)
+	if err != nil {
+		slog.Error("Cannot load sensor config", "error", err)
+		os.Exit(1)
+	}
+
+	collector := newCollector()
+	prometheus.MustRegister(collector)
+
+	for _, cfg := range configs {
+		slog.Info("Starting sensor poller", "sensor", cfg.Name, "driver", cfg.Driver, "port", cfg.Port)
+		go recordMetrics(cfg, collector)
+	}
+
+	if *envSensorDriver != "" {
+		if _, ok := envSensorDrivers[*envSensorDriver]; !ok {
+			slog.Error("Unknown companion sensor driver", "driver", *envSensorDriver)
+			os.Exit(1)
+		}
+		slog.Info("Starting companion sensor poller", "driver", *envSensorDriver, "port", *envPortPath)
+		go recordEnvMetrics(*envSensorDriver, *envPortPath, collector)
+	}
 
 	slog.Info("Starting HTTP server", "address", *listenAddress)
 	http.Handle("/metrics", promhttp.Handler())
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/jacobsa/go-serial/serial"
+)
+
+// pms7003FrameLen is the length in bytes of a PMS7003 active-mode output
+// frame: 2 start bytes, 2 length bytes, 13 data words and a 2-byte checksum.
+const pms7003FrameLen = 32
+
+// pms7003Sensor drives a Plantower PMS7003 over its UART in the factory
+// default active mode, where the sensor streams a frame roughly once a
+// second without being asked.
+type pms7003Sensor struct {
+	name string
+	port io.ReadWriteCloser
+	r    *bufio.Reader
+}
+
+func newPms7003Sensor(name, port string) (Sensor, error) {
+	options := serial.OpenOptions{
+		PortName:        port,
+		BaudRate:        9600,
+		DataBits:        8,
+		StopBits:        1,
+		MinimumReadSize: 1,
+	}
+
+	var p io.ReadWriteCloser
+	if err := withRetry(name, "connect", func() error {
+		f, err := serial.Open(options)
+		if err != nil {
+			return err
+		}
+		p = f
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return &pms7003Sensor{name: name, port: p, r: bufio.NewReader(p)}, nil
+}
+
+// Configure is a no-op: the PMS7003 has no concept of a polling cycle and
+// streams continuously; cycle only governs how often the exporter's caller
+// reads a frame off that stream.
+func (s *pms7003Sensor) Configure(cycle int) error {
+	return nil
+}
+
+func (s *pms7003Sensor) Read() (Measurement, error) {
+	var m Measurement
+	err := withRetry(s.name, "get", func() error {
+		frame, err := s.readFrame()
+		if err != nil {
+			return err
+		}
+		// Data5/Data6: PM2.5/PM10 under atmospheric environment, µg/m3.
+		m = Measurement{
+			PM25: float64(binary.BigEndian.Uint16(frame[12:14])),
+			PM10: float64(binary.BigEndian.Uint16(frame[14:16])),
+		}
+		return nil
+	})
+	return m, err
+}
+
+func (s *pms7003Sensor) readFrame() ([]byte, error) {
+	for {
+		b, err := s.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b != 0x42 {
+			continue
+		}
+
+		frame := make([]byte, pms7003FrameLen)
+		frame[0] = b
+		if _, err := io.ReadFull(s.r, frame[1:]); err != nil {
+			return nil, err
+		}
+		if frame[1] != 0x4d {
+			continue
+		}
+
+		var checksum uint16
+		for _, c := range frame[:pms7003FrameLen-2] {
+			checksum += uint16(c)
+		}
+		if checksum != binary.BigEndian.Uint16(frame[pms7003FrameLen-2:]) {
+			return nil, fmt.Errorf("pms7003: checksum mismatch")
+		}
+
+		return frame, nil
+	}
+}
+
+func (s *pms7003Sensor) Close() error {
+	return s.port.Close()
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/ryszard/sds011/go/sds011"
+)
+
+var (
+	sensorAwake = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "airquality_sensor_awake",
+		Help: "Whether the sensor is currently awake (1) or sleeping between duty-cycle samples (0)",
+	},
+		[]string{"sensor"})
+	sensorMeasurementsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "airquality_sensor_measurements_total",
+		Help: "Total number of individual samples taken during duty-cycle warmup/averaging",
+	},
+		[]string{"sensor"})
+	sensorLastWarmupSeconds = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "airquality_sensor_last_warmup_seconds",
+		Help: "Duration of the last duty-cycle post-wake warmup wait",
+	},
+		[]string{"sensor"})
+)
+
+// sds011Sensor adapts github.com/ryszard/sds011 to the Sensor interface.
+//
+// In duty-cycle mode it keeps the sensor in continuous (cycle=0) mode but
+// drives sleep/wake itself: asleep between reads, awake for a warmup period
+// plus a handful of samples that are averaged, then back to sleep for the
+// remainder of the configured cycle. This trades away the sensor's own
+// cycle timer in exchange for fine control over laser on-time, since the
+// SDS011 laser diode is the part that wears out.
+type sds011Sensor struct {
+	name   string
+	sensor *sds011.Sensor
+
+	dutyCycle    bool
+	warmup       time.Duration
+	samples      int
+	cycleMinutes int
+}
+
+func newSds011Sensor(name, port string) (Sensor, error) {
+	var sensor *sds011.Sensor
+	if err := withRetry(name, "connect", func() error {
+		s, err := sds011.New(port)
+		if err != nil {
+			return err
+		}
+		sensor = s
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := withRetry(name, "make_passive", sensor.MakePassive); err != nil {
+		sensor.Close()
+		return nil, err
+	}
+
+	samples := *dutyCycleSamples
+	if samples < 1 {
+		samples = 1
+	}
+
+	return &sds011Sensor{
+		name:      name,
+		sensor:    sensor,
+		dutyCycle: *dutyCycle,
+		warmup:    *dutyCycleWarmup,
+		samples:   samples,
+	}, nil
+}
+
+func (s *sds011Sensor) Configure(cycle int) error {
+	s.cycleMinutes = cycle
+
+	if s.dutyCycle {
+		slog.Info("Duty-cycle mode enabled, setting continuous sensor cycle", "sensor", s.name)
+		if err := withRetry(s.name, "set_cycle", func() error { return s.sensor.SetCycle(0) }); err != nil {
+			return err
+		}
+	} else if *forceSetCycle {
+		slog.Info("Setting sensor cycle", "sensor", s.name, "minutes", cycle)
+		if err := withRetry(s.name, "set_cycle", func() error { return s.sensor.SetCycle(uint8(cycle)) }); err != nil {
+			return err
+		}
+	} else {
+		var currentCycle uint8
+		if err := withRetry(s.name, "get_cycle", func() error {
+			c, err := s.sensor.Cycle()
+			if err != nil {
+				return err
+			}
+			currentCycle = c
+			return nil
+		}); err != nil {
+			return err
+		}
+		if currentCycle != uint8(cycle) {
+			slog.Info("Setting sensor cycle", "sensor", s.name, "minutes", cycle)
+			if err := withRetry(s.name, "set_cycle", func() error { return s.sensor.SetCycle(uint8(cycle)) }); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.dutyCycle {
+		// Stay in passive/query mode: dutyCycleRead pulls one on-demand
+		// sample per read via Query, rather than relying on the sensor's
+		// active-mode stream, which would just queue up in the serial
+		// buffer while the sensor sleeps between duty-cycle wakes.
+		if err := withRetry(s.name, "sleep", s.sensor.Sleep); err != nil {
+			return err
+		}
+		sensorAwake.WithLabelValues(s.name).Set(0)
+		return nil
+	}
+
+	slog.Info("Switching sensor to active mode", "sensor", s.name)
+	if err := withRetry(s.name, "make_active", s.sensor.MakeActive); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *sds011Sensor) Read() (Measurement, error) {
+	if s.dutyCycle {
+		return s.dutyCycleRead()
+	}
+	return s.readOnce()
+}
+
+func (s *sds011Sensor) readOnce() (Measurement, error) {
+	var point *sds011.Point
+	err := withRetryStreaming(s.name, "get", func() error {
+		p, err := s.sensor.Get()
+		if err != nil {
+			return err
+		}
+		point = p
+		return nil
+	})
+	if err != nil {
+		return Measurement{}, err
+	}
+
+	return Measurement{PM25: point.PM25, PM10: point.PM10}, nil
+}
+
+// queryOnce requests and reads back a single fresh reading. Unlike readOnce,
+// which reads whatever frame the sensor's active-mode stream produces next
+// (possibly one already queued from before the sensor was ready), this
+// prompts the sensor for a new measurement on demand, which only works while
+// it's in passive/query mode.
+func (s *sds011Sensor) queryOnce() (Measurement, error) {
+	var point *sds011.Point
+	err := withRetry(s.name, "query", func() error {
+		p, err := s.sensor.Query()
+		if err != nil {
+			return err
+		}
+		point = p
+		return nil
+	})
+	if err != nil {
+		return Measurement{}, err
+	}
+
+	return Measurement{PM25: point.PM25, PM10: point.PM10}, nil
+}
+
+// dutyCycleRead wakes the sensor, waits for the fan to stabilize the
+// reading, averages a handful of samples, then sleeps the sensor again for
+// whatever remains of the configured cycle.
+func (s *sds011Sensor) dutyCycleRead() (Measurement, error) {
+	start := time.Now()
+
+	slog.Info("Waking sensor for duty-cycle sample", "sensor", s.name)
+	if err := withRetry(s.name, "wake", s.sensor.Awake); err != nil {
+		return Measurement{}, err
+	}
+	sensorAwake.WithLabelValues(s.name).Set(1)
+
+	time.Sleep(s.warmup)
+	sensorLastWarmupSeconds.WithLabelValues(s.name).Set(s.warmup.Seconds())
+
+	var pm25Sum, pm10Sum float64
+	for i := 0; i < s.samples; i++ {
+		m, err := s.queryOnce()
+		if err != nil {
+			sensorAwake.WithLabelValues(s.name).Set(0)
+			_ = withRetry(s.name, "sleep", s.sensor.Sleep)
+			return Measurement{}, err
+		}
+		pm25Sum += m.PM25
+		pm10Sum += m.PM10
+		sensorMeasurementsTotal.WithLabelValues(s.name).Inc()
+	}
+
+	measurement := Measurement{
+		PM25: pm25Sum / float64(s.samples),
+		PM10: pm10Sum / float64(s.samples),
+	}
+
+	// The samples are already in hand at this point, so a failure putting
+	// the sensor back to sleep shouldn't discard a good measurement or
+	// force a full reconnect; it'll simply be retried on the next wake.
+	if err := withRetry(s.name, "sleep", s.sensor.Sleep); err != nil {
+		slog.Warn("Failed to sleep sensor after duty-cycle sample", "sensor", s.name, "error", err)
+	}
+	sensorAwake.WithLabelValues(s.name).Set(0)
+
+	if remaining := time.Duration(s.cycleMinutes)*time.Minute - time.Since(start); remaining > 0 {
+		time.Sleep(remaining)
+	}
+
+	return measurement, nil
+}
+
+func (s *sds011Sensor) Close() error {
+	s.sensor.Close()
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+// Measurement is a single PM reading taken from a sensor.
+type Measurement struct {
+	PM25 float64
+	PM10 float64
+}
+
+// Sensor is implemented by every supported air quality sensor driver,
+// letting the exporter poll heterogeneous hardware through one interface.
+type Sensor interface {
+	// Configure applies the polling cycle (in minutes) to the sensor and
+	// brings it into a state ready for Read. Drivers whose hardware has no
+	// concept of a cycle may ignore it.
+	Configure(cycle int) error
+	// Read blocks until a measurement is available and returns it.
+	Read() (Measurement, error)
+	Close() error
+}
+
+// SensorFactory connects to a sensor reachable at port. name identifies the
+// sensor in logs and error metrics.
+type SensorFactory func(name, port string) (Sensor, error)
+
+// sensorDrivers maps a config "driver" value to its factory. Drivers that
+// depend on platform-specific access (e.g. I2C) register themselves from an
+// init in their own build-tagged file instead of being listed here directly,
+// so the package still compiles on platforms that lack that access.
+var sensorDrivers = map[string]SensorFactory{
+	"sds011":  newSds011Sensor,
+	"pms7003": newPms7003Sensor,
+}
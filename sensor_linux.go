@@ -0,0 +1,7 @@
+//go:build linux
+
+package main
+
+func init() {
+	sensorDrivers["sps30"] = newSps30Sensor
+}